@@ -0,0 +1,90 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+)
+
+// tinyGeoLite2CountryMMDB is a minimal, hand-built MaxMind DB fixture
+// (metadata + a single /0 node pointing at {"country":{"iso_code":"US"}})
+// used to exercise the MaxMind fallback path without shipping a real
+// GeoLite2 database into the test binary.
+var tinyGeoLite2CountryMMDB = base64.StdEncoding.EncodeToString(buildTestMMDB())
+
+// newBenchHostEmulator spins up a proxytest host emulator with this
+// plugin's vmContext loaded, mirroring the integrated proxywasm
+// benchmarking used for other meshes: the plugin's callbacks are driven
+// through the same host ABI calls Envoy would make, with proxytest
+// emulating the host in-process.
+func newBenchHostEmulator(b *testing.B, pluginConfig string) (proxytest.HostEmulator, uint32) {
+	b.Helper()
+
+	opt := proxytest.NewEmulatorOption().
+		WithVMContext(&vmContext{}).
+		WithPluginConfiguration([]byte(pluginConfig))
+	host, reset := proxytest.NewHostEmulator(opt)
+	b.Cleanup(reset)
+
+	if status := host.StartPlugin(); status != types.OnPluginStartStatusOK {
+		b.Fatalf("failed to start plugin: %v", status)
+	}
+
+	contextID := host.InitializeHttpContext()
+	return host, contextID
+}
+
+// BenchmarkOnHttpRequestHeaders measures per-request overhead of the
+// plugin's request header callback across its three resolution paths.
+func BenchmarkOnHttpRequestHeaders(b *testing.B) {
+	b.Run("fast_path_property_present", func(b *testing.B) {
+		host, contextID := newBenchHostEmulator(b, `{}`)
+		host.SetProperty([]string{"request", "client_region"}, []byte("US"))
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			host.CallOnRequestHeaders(contextID, nil, false)
+		}
+	})
+
+	b.Run("fallback_path_header_removed", func(b *testing.B) {
+		host, contextID := newBenchHostEmulator(b, `{}`)
+		// No request.client_region property and no MaxMind DB configured:
+		// the plugin falls through to removing the header on every call.
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			host.CallOnRequestHeaders(contextID, nil, false)
+		}
+	})
+
+	b.Run("maxmind_fallback_path", func(b *testing.B) {
+		cfg := `{"mmdb_base64":"` + tinyGeoLite2CountryMMDB + `"}`
+		host, contextID := newBenchHostEmulator(b, cfg)
+		host.SetProperty([]string{"source", "address"}, []byte("203.0.113.1:1234"))
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			host.CallOnRequestHeaders(contextID, nil, false)
+		}
+	})
+}
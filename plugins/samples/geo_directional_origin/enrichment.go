@@ -0,0 +1,148 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
+const (
+	euContinentCode = "EU"
+
+	defaultCityHeader        = "x-geo-city"
+	defaultSubdivisionHeader = "x-geo-subdivision"
+	defaultASNHeader         = "x-geo-asn"
+	defaultCoordsHeader      = "x-geo-coords"
+)
+
+// Property paths are passed to proxywasm.GetProperty as one token per path
+// segment; see the note on the paths declared in plugin.go.
+var (
+	clientCityPropertyPath              = []string{"request", "client_city"}
+	clientRegionSubdivisionPropertyPath = []string{"request", "client_region_subdivision"}
+	clientASNPropertyPath               = []string{"request", "client_asn"}
+	clientLatLongPropertyPath           = []string{"request", "client_lat_long"}
+)
+
+// enrichmentConfig drives which geo properties get translated into request
+// headers, under what names, whether existing headers are overwritten or
+// appended to, and whether the emitted values are anonymized for privacy
+// compliance (e.g. GDPR) on EU-origin traffic.
+type enrichmentConfig struct {
+	EmitCity        bool `json:"emit_city"`
+	EmitSubdivision bool `json:"emit_subdivision"`
+	EmitASN         bool `json:"emit_asn"`
+	EmitCoords      bool `json:"emit_coords"`
+
+	CityHeader        string `json:"city_header"`
+	SubdivisionHeader string `json:"subdivision_header"`
+	ASNHeader         string `json:"asn_header"`
+	CoordsHeader      string `json:"coords_header"`
+
+	AppendHeaders bool `json:"append_headers"`
+
+	Anonymize      bool `json:"anonymize"`
+	CoordPrecision int  `json:"coord_precision"`
+}
+
+func (c *enrichmentConfig) setDefaults() {
+	if c.CityHeader == "" {
+		c.CityHeader = defaultCityHeader
+	}
+	if c.SubdivisionHeader == "" {
+		c.SubdivisionHeader = defaultSubdivisionHeader
+	}
+	if c.ASNHeader == "" {
+		c.ASNHeader = defaultASNHeader
+	}
+	if c.CoordsHeader == "" {
+		c.CoordsHeader = defaultCoordsHeader
+	}
+}
+
+// enrichHeaders writes the configured subset of geo properties as request
+// headers. continent and subdivision are the previously-resolved values
+// (from the host property or the mmdb fallback); continent is additionally
+// used to drop the city field for EU-origin traffic when anonymize mode is
+// on. City, ASN and coordinates are only ever available from the host, since
+// the MaxMind fallback only loads a GeoLite2-Country-shaped database.
+func (ctx *httpContext) enrichHeaders(continent, subdivision string) {
+	cfg := &ctx.plugin.config.enrichmentConfig
+
+	if cfg.EmitCity && !(cfg.Anonymize && continent == euContinentCode) {
+		if city, err := proxywasm.GetProperty(clientCityPropertyPath); err == nil && len(city) > 0 {
+			ctx.setHeader(cfg.CityHeader, string(city), cfg.AppendHeaders)
+		}
+	}
+
+	if cfg.EmitSubdivision && subdivision != "" {
+		ctx.setHeader(cfg.SubdivisionHeader, subdivision, cfg.AppendHeaders)
+	}
+
+	if cfg.EmitASN {
+		if asn, err := proxywasm.GetProperty(clientASNPropertyPath); err == nil && len(asn) > 0 {
+			ctx.setHeader(cfg.ASNHeader, string(asn), cfg.AppendHeaders)
+		}
+	}
+
+	if cfg.EmitCoords {
+		if latLong, err := proxywasm.GetProperty(clientLatLongPropertyPath); err == nil && len(latLong) > 0 {
+			value := string(latLong)
+			if cfg.Anonymize {
+				value = roundCoords(value, cfg.CoordPrecision)
+			}
+			ctx.setHeader(cfg.CoordsHeader, value, cfg.AppendHeaders)
+		}
+	}
+}
+
+func (ctx *httpContext) setHeader(name, value string, appendHeader bool) {
+	var err error
+	if appendHeader {
+		err = proxywasm.AddHttpRequestHeader(name, value)
+	} else {
+		err = proxywasm.ReplaceHttpRequestHeader(name, value)
+	}
+	if err != nil {
+		proxywasm.LogWarnf("geo_routing: failed to set %s header: %v", name, err)
+	}
+}
+
+// roundCoords parses a "lat,long" value and rounds both components to
+// precision decimal places, coarsening the location for privacy-preserving
+// anonymize mode. Values that cannot be parsed are passed through unchanged.
+func roundCoords(latLong string, precision int) string {
+	if precision < 0 {
+		precision = 0
+	}
+	parts := strings.SplitN(latLong, ",", 2)
+	if len(parts) != 2 {
+		return latLong
+	}
+	lat, errLat := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	long, errLong := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errLat != nil || errLong != nil {
+		return latLong
+	}
+	factor := math.Pow(10, float64(precision))
+	lat = math.Round(lat*factor) / factor
+	long = math.Round(long*factor) / factor
+	return fmt.Sprintf("%.*f,%.*f", precision, lat, precision, long)
+}
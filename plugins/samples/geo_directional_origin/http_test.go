@@ -0,0 +1,107 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+)
+
+// newTestHostEmulator mirrors newBenchHostEmulator, but for ordinary
+// correctness tests rather than benchmarks.
+func newTestHostEmulator(t *testing.T, pluginConfig string) (proxytest.HostEmulator, uint32) {
+	t.Helper()
+
+	opt := proxytest.NewEmulatorOption().
+		WithVMContext(&vmContext{}).
+		WithPluginConfiguration([]byte(pluginConfig))
+	host, reset := proxytest.NewHostEmulator(opt)
+	t.Cleanup(reset)
+
+	if status := host.StartPlugin(); status != types.OnPluginStartStatusOK {
+		t.Fatalf("failed to start plugin: %v", status)
+	}
+
+	contextID := host.InitializeHttpContext()
+	return host, contextID
+}
+
+func TestOnHttpRequestHeaders_FastPathUsesHostProperty(t *testing.T) {
+	host, contextID := newTestHostEmulator(t, `{}`)
+	host.SetProperty([]string{"request", "client_region"}, []byte("US"))
+
+	host.CallOnRequestHeaders(contextID, nil, false)
+
+	headers := host.GetCurrentRequestHeaders(contextID)
+	if got, ok := headerValue(headers, defaultCountryHeader); !ok || got != "US" {
+		t.Fatalf("%s header = %q, %v, want %q, true", defaultCountryHeader, got, ok, "US")
+	}
+}
+
+func TestOnHttpRequestHeaders_NoResolutionRemovesHeader(t *testing.T) {
+	host, contextID := newTestHostEmulator(t, `{}`)
+
+	host.CallOnRequestHeaders(contextID, [][2]string{{defaultCountryHeader, "client-forged"}}, false)
+
+	headers := host.GetCurrentRequestHeaders(contextID)
+	if _, ok := headerValue(headers, defaultCountryHeader); ok {
+		t.Fatalf("%s header still present, want removed when no geo data is resolvable", defaultCountryHeader)
+	}
+}
+
+// TestOnHttpRequestHeaders_NoResolutionClearsSignature guards against a
+// client-forged signature/timestamp pair surviving a request where this
+// plugin didn't resolve (and so didn't sign) a country code.
+func TestOnHttpRequestHeaders_NoResolutionClearsSignature(t *testing.T) {
+	cfg := `{"signing_enabled":true,"signing_secrets":["s3cr3t"]}`
+	host, contextID := newTestHostEmulator(t, cfg)
+
+	host.CallOnRequestHeaders(contextID, [][2]string{
+		{defaultSignatureHeader, "forged-signature"},
+		{defaultTimestampHeader, "123"},
+	}, false)
+
+	headers := host.GetCurrentRequestHeaders(contextID)
+	if _, ok := headerValue(headers, defaultSignatureHeader); ok {
+		t.Fatalf("%s header still present, want removed when no country code was signed", defaultSignatureHeader)
+	}
+	if _, ok := headerValue(headers, defaultTimestampHeader); ok {
+		t.Fatalf("%s header still present, want removed when no country code was signed", defaultTimestampHeader)
+	}
+}
+
+func TestOnHttpRequestHeaders_MaxMindFallbackResolvesCountry(t *testing.T) {
+	cfg := `{"mmdb_base64":"` + tinyGeoLite2CountryMMDB + `"}`
+	host, contextID := newTestHostEmulator(t, cfg)
+	host.SetProperty([]string{"source", "address"}, []byte("203.0.113.1:1234"))
+
+	host.CallOnRequestHeaders(contextID, nil, false)
+
+	headers := host.GetCurrentRequestHeaders(contextID)
+	if got, ok := headerValue(headers, defaultCountryHeader); !ok || got != "US" {
+		t.Fatalf("%s header = %q, %v, want %q, true", defaultCountryHeader, got, ok, "US")
+	}
+}
+
+func headerValue(headers [][2]string, name string) (string, bool) {
+	for _, h := range headers {
+		if h[0] == name {
+			return h[1], true
+		}
+	}
+	return "", false
+}
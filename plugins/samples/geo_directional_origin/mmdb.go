@@ -0,0 +1,293 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+)
+
+// metadataMarker delimits the end of the data section from the metadata
+// section in a MaxMind DB file, per the format spec:
+// https://maxmind.github.io/MaxMind-DB/
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// mmdbReader is a minimal, allocation-light, pure-Go reader for MaxMind DB
+// (.mmdb) files. It never touches the filesystem or makes syscalls, so it is
+// safe to use inside the WASM sandbox: callers load the database bytes once
+// (e.g. from plugin config) and keep them resident in linear memory.
+type mmdbReader struct {
+	data           []byte
+	nodeCount      uint32
+	recordSize     uint16
+	ipVersion      uint16
+	searchTreeSize int
+}
+
+// newMMDBReader parses the metadata section of data and returns a reader
+// ready to answer Lookup calls. data is retained by reference, not copied.
+func newMMDBReader(data []byte) (*mmdbReader, error) {
+	markerIdx := bytes.LastIndex(data, metadataMarker)
+	if markerIdx < 0 {
+		return nil, errors.New("mmdb: metadata marker not found, not a valid MaxMind DB")
+	}
+
+	raw, _, err := decodeMMDBValue(data, markerIdx+len(metadataMarker))
+	if err != nil {
+		return nil, errors.New("mmdb: failed to decode metadata: " + err.Error())
+	}
+	meta, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("mmdb: metadata section is not a map")
+	}
+
+	r := &mmdbReader{data: data}
+	r.nodeCount = toUint32(meta["node_count"])
+	r.recordSize = toUint16(meta["record_size"])
+	r.ipVersion = toUint16(meta["ip_version"])
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, errors.New("mmdb: unsupported record_size")
+	}
+	r.searchTreeSize = int(r.nodeCount) * int(r.recordSize) * 2 / 8
+	return r, nil
+}
+
+// Lookup walks the binary search tree for ip and returns the decoded data
+// record, or (nil, false, nil) if ip has no entry in the database.
+func (r *mmdbReader) Lookup(ip net.IP) (map[string]interface{}, bool, error) {
+	var ipBytes []byte
+	if r.ipVersion == 4 {
+		v4 := ip.To4()
+		if v4 == nil {
+			return nil, false, errors.New("mmdb: database only supports IPv4 lookups")
+		}
+		ipBytes = v4
+	} else if v4 := ip.To4(); v4 != nil {
+		// MaxMind embeds IPv4 addresses in an IPv6 tree under the all-zero
+		// ::/96 prefix (not the RFC 4291 ::ffff:a.b.c.d mapped form that
+		// net.IP.To16 produces), so build that representation explicitly:
+		// 96 zero bits followed by the 32-bit address. The 96 leading zero
+		// bits then walk the same root-to-leaf path the database expects.
+		ipBytes = append(make([]byte, 12), v4...)
+	} else {
+		v6 := ip.To16()
+		if v6 == nil {
+			return nil, false, errors.New("mmdb: invalid IP address")
+		}
+		ipBytes = v6
+	}
+
+	node := 0
+	for i := 0; i < len(ipBytes)*8; i++ {
+		if uint32(node) >= r.nodeCount {
+			break
+		}
+		bit := (ipBytes[i/8] >> uint(7-i%8)) & 1
+		rec, err := r.readRecord(node, int(bit))
+		if err != nil {
+			return nil, false, err
+		}
+		switch {
+		case rec == r.nodeCount:
+			return nil, false, nil
+		case rec > r.nodeCount:
+			// The data section starts after the search tree and its 16-byte
+			// separator. Decoding against that slice makes data-section
+			// pointers, which are relative to its start, resolve correctly.
+			dataStart := r.searchTreeSize + 16
+			offset := int(rec-r.nodeCount) - 16
+			if dataStart > len(r.data) || offset < 0 {
+				return nil, false, errors.New("mmdb: data section offset out of range")
+			}
+			val, _, err := decodeMMDBValue(r.data[dataStart:], offset)
+			if err != nil {
+				return nil, false, err
+			}
+			m, _ := val.(map[string]interface{})
+			return m, true, nil
+		default:
+			node = int(rec)
+		}
+	}
+	return nil, false, nil
+}
+
+// readRecord returns the (which)-th record (0=left, 1=right) of the node-th
+// node in the search tree.
+func (r *mmdbReader) readRecord(node, which int) (uint32, error) {
+	recordBytes := int(r.recordSize) / 4 // bytes for a full node (two records)
+	nodeOffset := node * recordBytes
+	if nodeOffset+recordBytes > len(r.data) {
+		return 0, errors.New("mmdb: search tree offset out of range")
+	}
+	switch r.recordSize {
+	case 24:
+		b := r.data[nodeOffset+which*3 : nodeOffset+which*3+3]
+		return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]), nil
+	case 28:
+		middle := r.data[nodeOffset+3]
+		if which == 0 {
+			b := r.data[nodeOffset : nodeOffset+3]
+			return uint32(middle>>4)<<24 | uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]), nil
+		}
+		b := r.data[nodeOffset+4 : nodeOffset+7]
+		return uint32(middle&0x0f)<<24 | uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]), nil
+	default: // 32
+		b := r.data[nodeOffset+which*4 : nodeOffset+which*4+4]
+		return binary.BigEndian.Uint32(b), nil
+	}
+}
+
+// decodeMMDBValue decodes a single data-section value starting at offset,
+// returning the value, the offset of the byte following it, and any error.
+// It supports the MaxMind DB control types needed by GeoLite2 databases:
+// pointer, utf8_string, double, bytes, uint16, uint32, map, int32, uint64,
+// array and boolean.
+func decodeMMDBValue(data []byte, offset int) (interface{}, int, error) {
+	if offset >= len(data) {
+		return nil, offset, errors.New("mmdb: offset out of range")
+	}
+	ctrl := data[offset]
+	typeNum := int(ctrl >> 5)
+	offset++
+	if typeNum == 0 { // extended type
+		if offset >= len(data) {
+			return nil, offset, errors.New("mmdb: truncated extended type")
+		}
+		typeNum = 7 + int(data[offset])
+		offset++
+	}
+
+	if typeNum == 1 { // pointer: size bits are encoded differently.
+		return decodeMMDBPointer(data, ctrl, offset)
+	}
+
+	size := int(ctrl & 0x1f)
+	switch size {
+	case 29:
+		size = 29 + int(data[offset])
+		offset++
+	case 30:
+		size = 285 + int(binary.BigEndian.Uint16(data[offset:offset+2]))
+		offset += 2
+	case 31:
+		b := append([]byte{0}, data[offset:offset+3]...)
+		size = 65821 + int(binary.BigEndian.Uint32(b))
+		offset += 3
+	}
+
+	switch typeNum {
+	case 2: // utf8_string
+		v := string(data[offset : offset+size])
+		return v, offset + size, nil
+	case 3: // double
+		v := math.Float64frombits(binary.BigEndian.Uint64(data[offset : offset+8]))
+		return v, offset + size, nil
+	case 4: // bytes
+		v := data[offset : offset+size]
+		return v, offset + size, nil
+	case 5: // uint16
+		return uint32(beUint(data[offset : offset+size])), offset + size, nil
+	case 6: // uint32
+		return uint32(beUint(data[offset : offset+size])), offset + size, nil
+	case 7: // map
+		m := make(map[string]interface{}, size)
+		var err error
+		for i := 0; i < size; i++ {
+			var key interface{}
+			key, offset, err = decodeMMDBValue(data, offset)
+			if err != nil {
+				return nil, offset, err
+			}
+			var val interface{}
+			val, offset, err = decodeMMDBValue(data, offset)
+			if err != nil {
+				return nil, offset, err
+			}
+			k, _ := key.(string)
+			m[k] = val
+		}
+		return m, offset, nil
+	case 8: // int32
+		return int32(beUint(data[offset : offset+size])), offset + size, nil
+	case 9: // uint64
+		return beUint(data[offset : offset+size]), offset + size, nil
+	case 11: // array
+		arr := make([]interface{}, size)
+		var err error
+		for i := 0; i < size; i++ {
+			arr[i], offset, err = decodeMMDBValue(data, offset)
+			if err != nil {
+				return nil, offset, err
+			}
+		}
+		return arr, offset, nil
+	case 14: // boolean: the value is stored in size itself, no payload bytes.
+		return size != 0, offset, nil
+	default:
+		return nil, offset + size, nil
+	}
+}
+
+// decodeMMDBPointer decodes a pointer control byte/value and follows it,
+// returning the pointed-to value and the offset following the pointer's own
+// bytes in the original stream (not the offset inside the followed data).
+func decodeMMDBPointer(data []byte, ctrl byte, offset int) (interface{}, int, error) {
+	pointerSize := (ctrl & 0x18) >> 3
+	msb := uint32(ctrl & 0x07)
+	var pointer uint32
+	var next int
+	switch pointerSize {
+	case 0:
+		pointer = msb<<8 | uint32(data[offset])
+		next = offset + 1
+	case 1:
+		pointer = (msb<<16 | uint32(data[offset])<<8 | uint32(data[offset+1])) + 2048
+		next = offset + 2
+	case 2:
+		pointer = (msb<<24 | uint32(data[offset])<<16 | uint32(data[offset+1])<<8 | uint32(data[offset+2])) + 526336
+		next = offset + 3
+	default:
+		pointer = binary.BigEndian.Uint32(data[offset : offset+4])
+		next = offset + 4
+	}
+	val, _, err := decodeMMDBValue(data, int(pointer))
+	return val, next, err
+}
+
+func beUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func toUint32(v interface{}) uint32 {
+	switch n := v.(type) {
+	case uint32:
+		return n
+	case uint64:
+		return uint32(n)
+	}
+	return 0
+}
+
+func toUint16(v interface{}) uint16 {
+	return uint16(toUint32(v))
+}
@@ -0,0 +1,92 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeMMDBValue(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want interface{}
+	}{
+		{"utf8_string", mmdbStringFixture("US"), "US"},
+		{"uint32", mmdbUintFixture(6, 42), uint32(42)},
+		{"boolean_true", []byte{0x01, 0x07}, true},
+		{"boolean_false", []byte{0x00, 0x07}, false},
+		{"map", mmdbMapFixture(1, concatFixture(mmdbStringFixture("k"), mmdbStringFixture("v"))), map[string]interface{}{"k": "v"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := decodeMMDBValue(tt.data, 0)
+			if err != nil {
+				t.Fatalf("decodeMMDBValue() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("decodeMMDBValue() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup_IPv4Database(t *testing.T) {
+	reader, err := newMMDBReader(buildTestMMDB())
+	if err != nil {
+		t.Fatalf("newMMDBReader() error = %v", err)
+	}
+
+	record, found, err := reader.Lookup(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if !found {
+		t.Fatal("Lookup() found = false, want true")
+	}
+	if iso := isoCode(record); iso != "US" {
+		t.Fatalf("Lookup() country.iso_code = %q, want %q", iso, "US")
+	}
+}
+
+// TestLookup_IPv4InIPv6Database regression-tests the ::/96 embedding: real
+// GeoLite2 databases are ip_version:6 even when resolving IPv4 clients, and
+// the 96 leading zero bits of that embedding (not the ::ffff: mapped form)
+// must be what walks the search tree.
+func TestLookup_IPv4InIPv6Database(t *testing.T) {
+	reader, err := newMMDBReader(buildTestMMDBv6())
+	if err != nil {
+		t.Fatalf("newMMDBReader() error = %v", err)
+	}
+
+	record, found, err := reader.Lookup(net.ParseIP("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if !found {
+		t.Fatal("Lookup() found = false, want true: IPv4 client should resolve via the ::/96 embedding in an IPv6 tree")
+	}
+	if iso := isoCode(record); iso != "US" {
+		t.Fatalf("Lookup() country.iso_code = %q, want %q", iso, "US")
+	}
+}
+
+func isoCode(record map[string]interface{}) string {
+	country, _ := record["country"].(map[string]interface{})
+	iso, _ := country["iso_code"].(string)
+	return iso
+}
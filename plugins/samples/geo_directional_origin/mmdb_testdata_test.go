@@ -0,0 +1,127 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// buildTestMMDB hand-assembles the smallest possible MaxMind DB file: a
+// single search tree node whose left and right records both point at one
+// data record, {"country": {"iso_code": "US"}}. Every IPv4 address
+// resolves to that single record, which is all the benchmarks need to
+// exercise the MaxMind fallback path.
+func buildTestMMDB() []byte {
+	const nodeCount = 1
+	const recordSize = 24
+
+	dataSection := mmdbMapFixture(1, concatFixture(
+		mmdbStringFixture("country"),
+		mmdbMapFixture(1, concatFixture(
+			mmdbStringFixture("iso_code"),
+			mmdbStringFixture("US"),
+		)),
+	))
+
+	// Both records point past the node count, marking them as data
+	// pointers rather than further tree nodes. Per the MaxMind DB spec, a
+	// record value of node_count+16 resolves to the start of the data
+	// section: Lookup decodes at rec - nodeCount - 16 within the data section,
+	// which begins at searchTreeSize + 16.
+	rec := nodeCount + 16
+	recBytes := []byte{byte(rec >> 16), byte(rec >> 8), byte(rec)}
+	searchTree := concatFixture(recBytes, recBytes)
+
+	separator := make([]byte, 16)
+
+	metadata := mmdbMapFixture(3, concatFixture(
+		mmdbStringFixture("node_count"), mmdbUintFixture(6, nodeCount),
+		mmdbStringFixture("record_size"), mmdbUintFixture(5, recordSize),
+		mmdbStringFixture("ip_version"), mmdbUintFixture(5, 4),
+	))
+
+	return concatFixture(searchTree, separator, dataSection, metadataMarker, metadata)
+}
+
+// buildTestMMDBv6 hand-assembles an ip_version:6 MaxMind DB file, as real
+// GeoLite2 databases ship regardless of whether the looked-up address is
+// itself IPv4 or IPv6. The search tree is a 96-node chain that only reaches
+// its data record by following the "0" branch at every one of the first 96
+// bits; any "1" branch leads to the not-found sentinel. This exact shape
+// distinguishes a correct IPv4-in-IPv6 lookup (96 leading zero bits, per the
+// all-zero ::/96 embedding) from the RFC 4291 ::ffff:a.b.c.d mapped form,
+// which sets bits 80-95 to 1 and would wrongly take the sentinel branch at
+// node 80.
+func buildTestMMDBv6() []byte {
+	const nodeCount = 96
+	const recordSize = 24
+
+	dataSection := mmdbMapFixture(1, concatFixture(
+		mmdbStringFixture("country"),
+		mmdbMapFixture(1, concatFixture(
+			mmdbStringFixture("iso_code"),
+			mmdbStringFixture("US"),
+		)),
+	))
+
+	dataRec := nodeCount + 16
+	notFoundRec := nodeCount
+
+	recBytes := func(rec int) []byte {
+		return []byte{byte(rec >> 16), byte(rec >> 8), byte(rec)}
+	}
+
+	var searchTree []byte
+	for i := 0; i < nodeCount; i++ {
+		left := i + 1
+		if i == nodeCount-1 {
+			left = dataRec
+		}
+		searchTree = append(searchTree, concatFixture(recBytes(left), recBytes(notFoundRec))...)
+	}
+
+	separator := make([]byte, 16)
+
+	metadata := mmdbMapFixture(3, concatFixture(
+		mmdbStringFixture("node_count"), mmdbUintFixture(6, nodeCount),
+		mmdbStringFixture("record_size"), mmdbUintFixture(5, recordSize),
+		mmdbStringFixture("ip_version"), mmdbUintFixture(5, 6),
+	))
+
+	return concatFixture(searchTree, separator, dataSection, metadataMarker, metadata)
+}
+
+func mmdbCtrlFixture(typeNum, size int) []byte {
+	if size >= 29 {
+		panic("mmdb test fixture: size too large for single-byte control encoding")
+	}
+	return []byte{byte(typeNum<<5 | size)}
+}
+
+func mmdbStringFixture(s string) []byte {
+	return concatFixture(mmdbCtrlFixture(2, len(s)), []byte(s))
+}
+
+func mmdbUintFixture(typeNum int, v byte) []byte {
+	return concatFixture(mmdbCtrlFixture(typeNum, 1), []byte{v})
+}
+
+func mmdbMapFixture(pairCount int, body []byte) []byte {
+	return concatFixture(mmdbCtrlFixture(7, pairCount), body)
+}
+
+func concatFixture(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
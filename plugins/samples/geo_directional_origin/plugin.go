@@ -16,16 +16,82 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
 	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
 )
 
 const (
-	clientRegionProperty = "request.client_region"
-	countryCodeHeader    = "x-country-code"
+	forwardedForHeader   = "x-forwarded-for"
+	defaultCountryHeader = "x-country-code"
+
+	statusHeader      = ":status"
+	failureStatusCode = 500
+
+	// tickPeriodMillis is the OnTick interval used as this plugin's logical
+	// clock. The proxy-wasm-go-sdk used here exposes no wall-clock hostcall,
+	// so failover cooldowns and signature timestamps are expressed in ticks
+	// (1 tick == 1 second) rather than real time.
+	tickPeriodMillis = 1000
+
+	mmdbFieldCountry     = "country"
+	mmdbFieldContinent   = "continent"
+	mmdbFieldSubdivision = "subdivision"
+)
+
+// Property paths are passed to proxywasm.GetProperty as one token per path
+// segment, not as a single dotted string; see
+// https://www.envoyproxy.io/docs/envoy/latest/intro/arch_overview/advanced/attributes.
+var (
+	clientRegionPropertyPath    = []string{"request", "client_region"}
+	clientContinentPropertyPath = []string{"request", "client_continent"}
+	sourceAddressPropertyPath   = []string{"source", "address"}
 )
 
-var clientRegionPropertyPath = []string{clientRegionProperty}
+// pluginConfig is the JSON plugin configuration. mmdb_base64 and
+// mmdb_shared_data_key are mutually exclusive ways to provide the embedded
+// GeoLite2-Country (or compatible) database: inline in the config, or via a
+// shared_data entry populated by the host ahead of plugin start. mmdb_fields
+// selects which fields to extract from the decoded mmdb record; it defaults
+// to ["country"] when empty so existing configs keep their prior behavior.
+type pluginConfig struct {
+	CountryHeader     string   `json:"country_header"`
+	MMDBBase64        string   `json:"mmdb_base64"`
+	MMDBSharedDataKey string   `json:"mmdb_shared_data_key"`
+	MMDBFields        []string `json:"mmdb_fields"`
+
+	routingConfig
+	enrichmentConfig
+	signingConfig
+}
+
+func (c *pluginConfig) setDefaults() {
+	if c.CountryHeader == "" {
+		c.CountryHeader = defaultCountryHeader
+	}
+	c.enrichmentConfig.setDefaults()
+	c.signingConfig.setDefaults()
+}
+
+// mmdbFieldEnabled reports whether field (one of mmdbFieldCountry,
+// mmdbFieldContinent, mmdbFieldSubdivision) should be extracted from mmdb
+// lookups.
+func (c *pluginConfig) mmdbFieldEnabled(field string) bool {
+	if len(c.MMDBFields) == 0 {
+		return field == mmdbFieldCountry
+	}
+	for _, f := range c.MMDBFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
 
 type vmContext struct {
 	types.DefaultVMContext
@@ -33,10 +99,15 @@ type vmContext struct {
 
 type pluginContext struct {
 	types.DefaultPluginContext
+	config pluginConfig
+	mmdb   *mmdbReader
+	tick   int64
 }
 
 type httpContext struct {
 	types.DefaultHttpContext
+	plugin  *pluginContext
+	cluster string
 }
 
 func main() {}
@@ -49,25 +120,215 @@ func (*vmContext) NewPluginContext(contextID uint32) types.PluginContext {
 	return &pluginContext{}
 }
 
-func (*pluginContext) NewHttpContext(contextID uint32) types.HttpContext {
-	return &httpContext{}
+func (ctx *pluginContext) OnPluginStart(pluginConfigurationSize int) types.OnPluginStartStatus {
+	data, err := proxywasm.GetPluginConfiguration()
+	if err != nil && err != types.ErrorStatusNotFound {
+		proxywasm.LogCriticalf("failed to load plugin configuration: %v", err)
+		return types.OnPluginStartStatusFailed
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &ctx.config); err != nil {
+			proxywasm.LogCriticalf("failed to parse plugin configuration: %v", err)
+			return types.OnPluginStartStatusFailed
+		}
+	}
+	ctx.config.setDefaults()
+
+	if len(ctx.config.Routes) > 0 || ctx.config.signingConfig.Enabled {
+		if err := proxywasm.SetTickPeriodMilliSeconds(tickPeriodMillis); err != nil {
+			proxywasm.LogWarnf("geo_routing: failed to start tick timer, failover cooldowns and signature timestamps will not advance: %v", err)
+		}
+	}
+
+	mmdbBytes, err := ctx.loadMMDB()
+	if err != nil {
+		proxywasm.LogWarnf("geo_routing: MaxMind DB fallback disabled: %v", err)
+		return types.OnPluginStartStatusOK
+	}
+	if mmdbBytes == nil {
+		// No database configured: fallback resolution is simply unavailable.
+		return types.OnPluginStartStatusOK
+	}
+
+	reader, err := newMMDBReader(mmdbBytes)
+	if err != nil {
+		proxywasm.LogWarnf("geo_routing: failed to parse MaxMind DB: %v", err)
+		return types.OnPluginStartStatusOK
+	}
+	ctx.mmdb = reader
+	return types.OnPluginStartStatusOK
+}
+
+// loadMMDB returns the raw MaxMind DB bytes from config, or nil if none was
+// configured.
+func (ctx *pluginContext) loadMMDB() ([]byte, error) {
+	if ctx.config.MMDBBase64 != "" {
+		return base64.StdEncoding.DecodeString(ctx.config.MMDBBase64)
+	}
+	if ctx.config.MMDBSharedDataKey != "" {
+		data, _, err := proxywasm.GetSharedData(ctx.config.MMDBSharedDataKey)
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	return nil, nil
+}
+
+func (ctx *pluginContext) NewHttpContext(contextID uint32) types.HttpContext {
+	return &httpContext{plugin: ctx}
+}
+
+// OnTick advances this plugin instance's logical clock by one tick. See
+// tickPeriodMillis for why this stands in for wall-clock time.
+func (ctx *pluginContext) OnTick() {
+	ctx.tick++
 }
 
 func (ctx *httpContext) OnHttpRequestHeaders(_ int, _ bool) types.Action {
-	countryCode, err := proxywasm.GetProperty(clientRegionPropertyPath)
+	country := ""
+	if countryCode, err := proxywasm.GetProperty(clientRegionPropertyPath); err == nil && len(countryCode) > 0 {
+		country = string(countryCode)
+	}
+
+	continent := ""
+	if continentCode, err := proxywasm.GetProperty(clientContinentPropertyPath); err == nil && len(continentCode) > 0 {
+		continent = string(continentCode)
+	}
 
-	if err == nil && len(countryCode) > 0 {
-		if err := proxywasm.ReplaceHttpRequestHeader(countryCodeHeader, string(countryCode)); err != nil {
-			proxywasm.LogWarnf("failed to set country code header: %v", err)
+	subdivision := ""
+	if sub, err := proxywasm.GetProperty(clientRegionSubdivisionPropertyPath); err == nil && len(sub) > 0 {
+		subdivision = string(sub)
+	}
+
+	// The host only omits request.client_region when it has no geolocation
+	// configured at all, so whenever that happens request.client_continent
+	// and request.client_region_subdivision are typically absent too: fall
+	// back to the embedded mmdb record for whichever of the three is still
+	// missing.
+	if (country == "" || continent == "" || subdivision == "") && ctx.plugin.mmdb != nil {
+		if mCountry, mContinent, mSubdivision, ok := ctx.lookupGeoFromClientIP(); ok {
+			if country == "" {
+				country = mCountry
+			}
+			if continent == "" {
+				continent = mContinent
+			}
+			if subdivision == "" {
+				subdivision = mSubdivision
+			}
+		}
+	}
+
+	if country != "" {
+		ctx.setCountryHeader(country)
+		ctx.signCountryCode(country)
+	} else {
+		if err := proxywasm.RemoveHttpRequestHeader(ctx.plugin.config.CountryHeader); err != nil {
+			proxywasm.LogWarnf("failed to remove country code header: %v", err)
 		}
+		ctx.clearSignature()
+	}
+
+	ctx.enrichHeaders(continent, subdivision)
+	ctx.cluster = ctx.routeRequest(country, continent)
+	return types.ActionContinue
+}
+
+// OnHttpResponseHeaders observes the upstream response for the cluster this
+// request was routed to and, on a server error, counts it against that
+// cluster's failover health.
+func (ctx *httpContext) OnHttpResponseHeaders(_ int, _ bool) types.Action {
+	if ctx.cluster == "" {
 		return types.ActionContinue
 	}
 
-	if err := proxywasm.RemoveHttpRequestHeader(countryCodeHeader); err != nil {
-		proxywasm.LogWarnf("failed to remove country code header: %v", err)
+	status, err := proxywasm.GetHttpResponseHeader(statusHeader)
+	if err != nil {
+		return types.ActionContinue
+	}
+	if code, convErr := strconv.Atoi(status); convErr == nil && code >= failureStatusCode {
+		recordUpstreamFailure(ctx.cluster, &ctx.plugin.config.routingConfig, ctx.plugin.tick)
 	}
 
 	return types.ActionContinue
 }
 
+func (ctx *httpContext) setCountryHeader(countryCode string) {
+	if err := proxywasm.ReplaceHttpRequestHeader(ctx.plugin.config.CountryHeader, countryCode); err != nil {
+		proxywasm.LogWarnf("failed to set country code header: %v", err)
+	}
+}
+
+// lookupGeoFromClientIP resolves the client's country, continent and
+// subdivision via the embedded MaxMind DB when the host did not populate
+// the corresponding request.client_* properties, e.g. because geolocation
+// wasn't configured upstream of this plugin. Only the fields enabled by
+// mmdb_fields are populated; the rest are returned as "".
+func (ctx *httpContext) lookupGeoFromClientIP() (country, continent, subdivision string, ok bool) {
+	ip := ctx.clientIP()
+	if ip == nil {
+		return "", "", "", false
+	}
+
+	record, found, err := ctx.plugin.mmdb.Lookup(ip)
+	if err != nil {
+		proxywasm.LogWarnf("geo_routing: MaxMind lookup failed: %v", err)
+		return "", "", "", false
+	}
+	if !found {
+		return "", "", "", false
+	}
+
+	cfg := &ctx.plugin.config
+	if cfg.mmdbFieldEnabled(mmdbFieldCountry) {
+		if c, ok := record["country"].(map[string]interface{}); ok {
+			country, _ = c["iso_code"].(string)
+		}
+	}
+	if cfg.mmdbFieldEnabled(mmdbFieldContinent) {
+		if c, ok := record["continent"].(map[string]interface{}); ok {
+			continent, _ = c["code"].(string)
+		}
+	}
+	if cfg.mmdbFieldEnabled(mmdbFieldSubdivision) {
+		if subs, ok := record["subdivisions"].([]interface{}); ok && len(subs) > 0 {
+			if s, ok := subs[0].(map[string]interface{}); ok {
+				subdivision, _ = s["iso_code"].(string)
+			}
+		}
+	}
+	return country, continent, subdivision, true
+}
+
+// clientIP returns the originating client address, preferring the host's
+// source.address property and falling back to the left-most entry of
+// X-Forwarded-For.
+func (ctx *httpContext) clientIP() net.IP {
+	if addr, err := proxywasm.GetProperty(sourceAddressPropertyPath); err == nil && len(addr) > 0 {
+		if ip := parseIPHost(string(addr)); ip != nil {
+			return ip
+		}
+	}
+
+	if values, err := proxywasm.GetHttpRequestHeader(forwardedForHeader); err == nil && values != "" {
+		first := strings.TrimSpace(strings.Split(values, ",")[0])
+		if ip := parseIPHost(first); ip != nil {
+			return ip
+		}
+	}
+
+	return nil
+}
+
+// parseIPHost parses an address that may include a port (host:port, or
+// [ipv6]:port) as well as a bare IP.
+func parseIPHost(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
+
 // [END serviceextensions_plugin_geo_routing]
@@ -0,0 +1,191 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
+const (
+	clusterNamePropertyKey = "cluster_name"
+	healthSharedDataPrefix = "geo_routing:health:"
+
+	defaultFailureThreshold   = 5
+	defaultFailureWindowTicks = 60 // 60s, at one tick per second
+	defaultCooldownTicks      = 30 // 30s, at one tick per second
+)
+
+var clusterNamePropertyPath = []string{clusterNamePropertyKey}
+
+// route is one entry of the region-to-upstream routing table: the ordered
+// list of candidate clusters for a country or continent code, tried in order
+// until a healthy one is found.
+type route struct {
+	Upstreams []string `json:"upstreams"`
+}
+
+// routingConfig is the subset of pluginConfig that drives upstream
+// selection and failover. Keys of Routes may be either ISO country codes
+// (e.g. "US") or continent codes (e.g. "NA"); a country code match takes
+// precedence over a continent one.
+type routingConfig struct {
+	Routes               map[string]route `json:"routes"`
+	DefaultCluster       string           `json:"default_cluster"`
+	FailureThreshold     int              `json:"failure_threshold"`
+	FailureWindowSeconds int              `json:"failure_window_seconds"`
+	CooldownSeconds      int              `json:"cooldown_seconds"`
+}
+
+func (c *routingConfig) failureThreshold() int {
+	if c.FailureThreshold > 0 {
+		return c.FailureThreshold
+	}
+	return defaultFailureThreshold
+}
+
+// failureWindowTicks returns the sliding window size in ticks. Since a tick
+// fires once per second, a FailureWindowSeconds of N seconds is N ticks.
+func (c *routingConfig) failureWindowTicks() int64 {
+	if c.FailureWindowSeconds > 0 {
+		return int64(c.FailureWindowSeconds)
+	}
+	return defaultFailureWindowTicks
+}
+
+// cooldownTicks returns the unhealthy cooldown period in ticks, see
+// failureWindowTicks.
+func (c *routingConfig) cooldownTicks() int64 {
+	if c.CooldownSeconds > 0 {
+		return int64(c.CooldownSeconds)
+	}
+	return defaultCooldownTicks
+}
+
+// clusterHealth is the per-cluster failover state persisted in shared data
+// so that it is consistent across all VM instances/workers. Window/cooldown
+// bounds are expressed in plugin ticks (see tickPeriodMillis), not
+// wall-clock time.
+type clusterHealth struct {
+	Failures           int   `json:"failures"`
+	WindowStartTick    int64 `json:"window_start_tick"`
+	UnhealthyUntilTick int64 `json:"unhealthy_until_tick"`
+}
+
+// routeRequest picks the first healthy upstream of the route for
+// countryCode, or for continentCode when no country route matches, and
+// overrides the request's target cluster. It returns
+// the cluster name it selected, or "" if no routing decision was made
+// (e.g. no matching route and no default cluster configured).
+func (ctx *httpContext) routeRequest(countryCode, continentCode string) string {
+	cfg := &ctx.plugin.config.routingConfig
+	if len(cfg.Routes) == 0 {
+		return ""
+	}
+
+	upstreams := cfg.Routes[countryCode].Upstreams
+	if len(upstreams) == 0 {
+		upstreams = cfg.Routes[continentCode].Upstreams
+	}
+
+	cluster := firstHealthyCluster(upstreams, cfg, ctx.plugin.tick)
+	if cluster == "" {
+		cluster = cfg.DefaultCluster
+	}
+	if cluster == "" {
+		return ""
+	}
+
+	if err := proxywasm.SetProperty(clusterNamePropertyPath, []byte(cluster)); err != nil {
+		proxywasm.LogWarnf("geo_routing: failed to override cluster_name: %v", err)
+		return ""
+	}
+	return cluster
+}
+
+func firstHealthyCluster(upstreams []string, cfg *routingConfig, nowTick int64) string {
+	for _, cluster := range upstreams {
+		if isClusterHealthy(cluster, nowTick) {
+			return cluster
+		}
+	}
+	return ""
+}
+
+// isClusterHealthy reports whether cluster is currently outside its cooldown
+// window as of nowTick. A cluster past its cooldown is treated as healthy
+// again so that traffic automatically re-probes it.
+func isClusterHealthy(cluster string, nowTick int64) bool {
+	health, _, err := getClusterHealth(cluster)
+	if err != nil {
+		// No recorded failures (or shared data unavailable): assume healthy.
+		return true
+	}
+	return health.UnhealthyUntilTick == 0 || nowTick >= health.UnhealthyUntilTick
+}
+
+// recordUpstreamFailure increments cluster's failure count within the
+// configured sliding window and, once the threshold is crossed, marks it
+// unhealthy for the cooldown period. It CAS-retries against shared data so
+// concurrent VM instances account failures atomically.
+func recordUpstreamFailure(cluster string, cfg *routingConfig, nowTick int64) {
+	for attempt := 0; attempt < 3; attempt++ {
+		health, cas, err := getClusterHealth(cluster)
+		if err != nil {
+			health = clusterHealth{}
+		}
+
+		if health.WindowStartTick == 0 || nowTick-health.WindowStartTick > cfg.failureWindowTicks() {
+			health.WindowStartTick = nowTick
+			health.Failures = 0
+		}
+		health.Failures++
+
+		if health.Failures >= cfg.failureThreshold() {
+			health.UnhealthyUntilTick = nowTick + cfg.cooldownTicks()
+			health.Failures = 0
+			health.WindowStartTick = 0
+		}
+
+		if setClusterHealth(cluster, health, cas) {
+			return
+		}
+		// CAS mismatch: another worker updated concurrently, retry.
+	}
+}
+
+func getClusterHealth(cluster string) (clusterHealth, uint32, error) {
+	var health clusterHealth
+	data, cas, err := proxywasm.GetSharedData(healthSharedDataPrefix + cluster)
+	if err != nil {
+		return health, 0, err
+	}
+	if len(data) == 0 {
+		return health, cas, nil
+	}
+	if err := json.Unmarshal(data, &health); err != nil {
+		return clusterHealth{}, cas, err
+	}
+	return health, cas, nil
+}
+
+func setClusterHealth(cluster string, health clusterHealth, cas uint32) bool {
+	data, err := json.Marshal(health)
+	if err != nil {
+		return false
+	}
+	return proxywasm.SetSharedData(healthSharedDataPrefix+cluster, data, cas) == nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/proxytest"
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm/types"
+)
+
+// newRoutingTestHost spins up a bare host emulator with no plugin
+// configuration: recordUpstreamFailure/isClusterHealthy only touch shared
+// data, which proxytest backs in-process, so no route config is needed.
+func newRoutingTestHost(t *testing.T) {
+	t.Helper()
+	opt := proxytest.NewEmulatorOption().WithVMContext(&vmContext{})
+	host, reset := proxytest.NewHostEmulator(opt)
+	t.Cleanup(reset)
+	if status := host.StartPlugin(); status != types.OnPluginStartStatusOK {
+		t.Fatalf("failed to start plugin: %v", status)
+	}
+}
+
+func TestIsClusterHealthy_NoRecordedFailures(t *testing.T) {
+	newRoutingTestHost(t)
+
+	if !isClusterHealthy("cluster-a", 100) {
+		t.Fatal("isClusterHealthy() = false, want true for a cluster with no recorded failures")
+	}
+}
+
+func TestRecordUpstreamFailure_BelowThreshold(t *testing.T) {
+	newRoutingTestHost(t)
+	cfg := &routingConfig{FailureThreshold: 3}
+
+	recordUpstreamFailure("cluster-a", cfg, 10)
+	recordUpstreamFailure("cluster-a", cfg, 11)
+
+	if !isClusterHealthy("cluster-a", 12) {
+		t.Fatal("isClusterHealthy() = false, want true: only 2 of 3 threshold failures recorded")
+	}
+}
+
+func TestRecordUpstreamFailure_ThresholdTripsCooldown(t *testing.T) {
+	newRoutingTestHost(t)
+	cfg := &routingConfig{FailureThreshold: 3, CooldownSeconds: 30}
+
+	recordUpstreamFailure("cluster-a", cfg, 10)
+	recordUpstreamFailure("cluster-a", cfg, 11)
+	recordUpstreamFailure("cluster-a", cfg, 12)
+
+	if isClusterHealthy("cluster-a", 13) {
+		t.Fatal("isClusterHealthy() = true, want false: cluster just crossed the failure threshold")
+	}
+	if isClusterHealthy("cluster-a", 41) {
+		t.Fatal("isClusterHealthy() = true, want false: still inside the 30-tick cooldown window")
+	}
+	if !isClusterHealthy("cluster-a", 42) {
+		t.Fatal("isClusterHealthy() = false, want true: cooldown window has elapsed, cluster should be re-probed")
+	}
+}
+
+func TestRecordUpstreamFailure_WindowResetsBetweenFailures(t *testing.T) {
+	newRoutingTestHost(t)
+	cfg := &routingConfig{FailureThreshold: 3, FailureWindowSeconds: 5}
+
+	recordUpstreamFailure("cluster-a", cfg, 10)
+	recordUpstreamFailure("cluster-a", cfg, 11)
+	// This failure arrives well outside the 5-tick sliding window, so it
+	// should restart the count at 1 rather than tripping the threshold.
+	recordUpstreamFailure("cluster-a", cfg, 30)
+
+	if !isClusterHealthy("cluster-a", 31) {
+		t.Fatal("isClusterHealthy() = false, want true: earlier failures fell outside the sliding window")
+	}
+}
+
+func TestFirstHealthyCluster_SkipsUnhealthyUpstream(t *testing.T) {
+	newRoutingTestHost(t)
+	cfg := &routingConfig{FailureThreshold: 1, CooldownSeconds: 30}
+
+	recordUpstreamFailure("primary", cfg, 10)
+
+	got := firstHealthyCluster([]string{"primary", "secondary"}, cfg, 11)
+	if got != "secondary" {
+		t.Fatalf("firstHealthyCluster() = %q, want %q", got, "secondary")
+	}
+}
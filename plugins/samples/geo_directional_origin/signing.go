@@ -0,0 +1,130 @@
+// Copyright 2026 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+
+	"github.com/proxy-wasm/proxy-wasm-go-sdk/proxywasm"
+)
+
+const (
+	defaultSignatureHeader = "x-country-code-sig"
+	defaultTimestampHeader = "x-country-code-sig-ts"
+)
+
+// signingConfig enables HMAC-SHA256 signing of the resolved country code so
+// that upstreams can trust it came from this plugin rather than a
+// client-forged header. Secrets[0] is the active signing key; any remaining
+// entries are prior keys that are still accepted for verification, which
+// lets operators roll the active key without a synchronized upstream
+// deploy (the rotation window).
+//
+// Known limitation: the timestamp is a per-VM-instance tick counter, not
+// wall-clock time (see signCountryCode), so it is only comparable to other
+// timestamps produced by the same worker. Upstreams that compare it across
+// requests handled by different workers cannot rely on it as a freshness or
+// anti-replay window.
+type signingConfig struct {
+	Enabled             bool     `json:"signing_enabled"`
+	Secrets             []string `json:"signing_secrets"`
+	SignatureHeader     string   `json:"signature_header"`
+	TimestampHeader     string   `json:"timestamp_header"`
+	SuppressPlainHeader bool     `json:"suppress_plain_header"`
+}
+
+func (c *signingConfig) setDefaults() {
+	if c.SignatureHeader == "" {
+		c.SignatureHeader = defaultSignatureHeader
+	}
+	if c.TimestampHeader == "" {
+		c.TimestampHeader = defaultTimestampHeader
+	}
+}
+
+// signCountryCode writes the signature and timestamp headers for
+// countryCode using the active signing secret, and (unless
+// suppress_plain_header is set) leaves the plain country header in place
+// for backwards compatibility with consumers that don't verify yet. The
+// timestamp is this plugin instance's tick counter (see tickPeriodMillis),
+// not wall-clock time: the proxy-wasm-go-sdk used here exposes no time
+// hostcall, so upstreams verifying the signature should treat it as an
+// opaque, monotonically increasing nonce rather than a Unix timestamp.
+func (ctx *httpContext) signCountryCode(countryCode string) {
+	cfg := &ctx.plugin.config.signingConfig
+	if !cfg.Enabled || len(cfg.Secrets) == 0 {
+		return
+	}
+
+	timestamp := strconv.FormatInt(ctx.plugin.tick, 10)
+	signature := signValue(cfg.Secrets[0], countryCode, timestamp)
+
+	if err := proxywasm.ReplaceHttpRequestHeader(cfg.SignatureHeader, signature); err != nil {
+		proxywasm.LogWarnf("geo_routing: failed to set signature header: %v", err)
+	}
+	if err := proxywasm.ReplaceHttpRequestHeader(cfg.TimestampHeader, timestamp); err != nil {
+		proxywasm.LogWarnf("geo_routing: failed to set signature timestamp header: %v", err)
+	}
+
+	if cfg.SuppressPlainHeader {
+		if err := proxywasm.RemoveHttpRequestHeader(ctx.plugin.config.CountryHeader); err != nil {
+			proxywasm.LogWarnf("geo_routing: failed to remove plain country header: %v", err)
+		}
+	}
+}
+
+// clearSignature removes the signature and timestamp headers, so that a
+// client-forged pair from the original request can't ride along on a
+// request where this plugin didn't resolve (and therefore didn't sign) a
+// country code.
+func (ctx *httpContext) clearSignature() {
+	cfg := &ctx.plugin.config.signingConfig
+	if !cfg.Enabled {
+		return
+	}
+
+	if err := proxywasm.RemoveHttpRequestHeader(cfg.SignatureHeader); err != nil {
+		proxywasm.LogWarnf("geo_routing: failed to remove signature header: %v", err)
+	}
+	if err := proxywasm.RemoveHttpRequestHeader(cfg.TimestampHeader); err != nil {
+		proxywasm.LogWarnf("geo_routing: failed to remove signature timestamp header: %v", err)
+	}
+}
+
+// signValue computes base64(HMAC-SHA256(secret, countryCode||timestamp)),
+// entirely in WASM-compiled Go with no host call.
+func signValue(secret, countryCode, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(countryCode))
+	mac.Write([]byte(timestamp))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature is the reference verification routine an upstream would
+// run to accept a signed country code header: it recomputes the HMAC over
+// countryCode and timestamp and accepts a match against the active secret
+// or any secret still within the rotation window.
+func VerifySignature(secrets []string, countryCode, timestamp, signature string) bool {
+	for _, secret := range secrets {
+		expected := signValue(secret, countryCode, timestamp)
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			return true
+		}
+	}
+	return false
+}